@@ -2,18 +2,29 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/proxy"
 )
 
 /* ========= COLORS ========= */
@@ -32,9 +43,20 @@ const (
 
 /* ========= TYPES ========= */
 type Result struct {
-	URL    string
-	Param  string
-	Chars  []string
+	URL      string   `json:"url"`
+	Param    string   `json:"param"`
+	Location string   `json:"location"`
+	Context  string   `json:"context"`
+	Chars    []string `json:"breaks"`
+}
+
+// bodyParam is a single mutable leaf found while walking a request body
+// template (a form field, or a JSON scalar reached via path).
+type bodyParam struct {
+	Location string // "body" or "json:$.a.b" for display/Result.Location
+	Key      string // form field name, or JSON path string
+	Value    string
+	jsonPath []interface{} // nil for form bodies; map keys (string) / slice indexes (int) otherwise
 }
 
 type headerFlags []string
@@ -47,23 +69,59 @@ func (h *headerFlags) Set(v string) error {
 
 /* ========= FLAGS ========= */
 var (
-	timeout     int
-	concurrency int
-	headers     headerFlags
+	timeout       int
+	concurrency   int
+	headers       headerFlags
+	method        string
+	bodyTemplate  string
+	contentType   string
+	scanFlag      string
+	cookieFlag    string
+	cookieJarFlag string
+	proxyFlag     string
+	forwardedFlag bool
+	insecureFlag  bool
+	outputFormat  string
+	resumeFlag    string
+
+	scanModes   map[string]bool
+	baseCookies []*http.Cookie
 )
 
+// candidateHeaders lists the request headers most commonly echoed back by
+// apps (error pages, "you are using X" banners, CSRF/debug toolbars).
+var candidateHeaders = []string{
+	"User-Agent", "Referer", "X-Forwarded-For", "X-Forwarded-Host", "Origin", "Accept-Language",
+}
+
 /* ========= MAIN ========= */
 func main() {
 	flag.IntVar(&timeout, "t", 10, "Request timeout (seconds)")
 	flag.IntVar(&concurrency, "c", 40, "Concurrency level")
 	flag.Var(&headers, "H", "Custom header (repeatable)")
+	flag.StringVar(&method, "X", "GET", "HTTP method")
+	flag.StringVar(&bodyTemplate, "d", "", "Request body template to scan (form or JSON, see --content-type)")
+	flag.StringVar(&contentType, "content-type", "application/x-www-form-urlencoded", "Content-Type of the -d body")
+	flag.StringVar(&scanFlag, "scan", "query", "Comma-separated scan modes: query,headers,cookies")
+	flag.StringVar(&cookieFlag, "cookie", "", "Cookie header to replay and fuzz, e.g. \"session=abc; lang=en\"")
+	flag.StringVar(&cookieJarFlag, "cookie-jar", "", "File of cookie lines (same syntax as -cookie) to replay and fuzz")
+	flag.StringVar(&proxyFlag, "proxy", "", "Upstream proxy URL (http://, https://, or socks5://)")
+	flag.BoolVar(&forwardedFlag, "forwarded", false, "Stamp X-Forwarded-For/Host/Proto on every request")
+	flag.BoolVar(&insecureFlag, "insecure", true, "Skip TLS certificate verification")
+	flag.StringVar(&outputFormat, "o", "text", "Output format: text, json, jsonl, sarif")
+	flag.StringVar(&resumeFlag, "resume", "", "Resume state file recording already-scanned URLs")
 	flag.Parse()
 
+	scanModes = parseScanModes(scanFlag)
+	baseCookies = loadBaseCookies()
+	resume := loadResumeState(resumeFlag)
+
 	client := buildClient()
 
 	sc := bufio.NewScanner(os.Stdin)
 	input := make(chan string)
 	results := make(chan Result)
+	doneURLs := make(chan string)
 
 	/* === WORKERS === */
 	var wg sync.WaitGroup
@@ -71,19 +129,70 @@ func main() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for rawURL := range input {
-				cleanURL := normalizeURL(rawURL)
-				params := findReflectedParams(client, cleanURL)
-				for _, p := range params {
-					chars := testSpecialChars(client, cleanURL, p)
-					if len(chars) > 0 {
-						results <- Result{
-							URL:   cleanURL,
-							Param: p,
-							Chars: chars,
+			for cleanURL := range input {
+				if scanModes["query"] {
+					params := findReflectedParams(client, cleanURL)
+					for _, p := range params {
+						ctx, chars := testSpecialChars(client, cleanURL, p)
+						if len(chars) > 0 {
+							results <- Result{
+								URL:      cleanURL,
+								Param:    p,
+								Location: "query",
+								Context:  ctx,
+								Chars:    chars,
+							}
+						}
+					}
+				}
+
+				if bodyTemplate != "" {
+					bodyParams := findReflectedBodyParams(client, cleanURL)
+					for _, bp := range bodyParams {
+						ctx, chars := testSpecialCharsBody(client, cleanURL, bp)
+						if len(chars) > 0 {
+							results <- Result{
+								URL:      cleanURL,
+								Param:    bp.Key,
+								Location: bp.Location,
+								Context:  ctx,
+								Chars:    chars,
+							}
+						}
+					}
+				}
+
+				if scanModes["headers"] {
+					for _, h := range findReflectedHeaders(client, cleanURL) {
+						ctx, chars := testSpecialCharsHeader(client, cleanURL, h)
+						if len(chars) > 0 {
+							results <- Result{
+								URL:      cleanURL,
+								Param:    h,
+								Location: "header:" + h,
+								Context:  ctx,
+								Chars:    chars,
+							}
+						}
+					}
+				}
+
+				if scanModes["cookies"] {
+					for _, name := range findReflectedCookies(client, cleanURL) {
+						ctx, chars := testSpecialCharsCookie(client, cleanURL, name)
+						if len(chars) > 0 {
+							results <- Result{
+								URL:      cleanURL,
+								Param:    name,
+								Location: "cookie:" + name,
+								Context:  ctx,
+								Chars:    chars,
+							}
 						}
 					}
 				}
+
+				doneURLs <- cleanURL
 			}
 		}()
 	}
@@ -91,42 +200,269 @@ func main() {
 	go func() {
 		wg.Wait()
 		close(results)
+		close(doneURLs)
 	}()
 
 	go func() {
 		for sc.Scan() {
 			line := strings.TrimSpace(sc.Text())
-			if line != "" {
-				input <- line
+			if line == "" {
+				continue
+			}
+			cleanURL := normalizeURL(line)
+			if resume.isDone(cleanURL) {
+				continue
 			}
+			input <- cleanURL
 		}
 		close(input)
 	}()
 
-	/* === GROUP RESULTS === */
+	/* === COLLECT & PRINT RESULTS === */
+	foundAny := printResults(results, doneURLs, resume)
+
+	if !foundAny && outputFormat == "text" {
+		fmt.Printf("%s[-] No reflected XSS parameters found%s\n", red, reset)
+	}
+}
+
+// printResults drains results (and the URLs they belong to as workers finish
+// them) in the format selected by -o, and reports whether anything was
+// found. JSONL is streamed as results arrive, so a URL's resume state is
+// marked done as soon as its findings are printed. json and sarif buffer
+// everything so they can emit one well-formed document — a URL is only
+// marked done once that document has actually been written, so an
+// interrupted scan doesn't lose buffered-but-unprinted findings on resume.
+func printResults(results <-chan Result, doneURLs <-chan string, resume *resumeState) bool {
 	foundAny := false
-	grouped := make(map[string]map[string][]string)
 
-	for r := range results {
-		foundAny = true
-		if _, ok := grouped[r.URL]; !ok {
-			grouped[r.URL] = make(map[string][]string)
+	var all []Result
+	grouped := make(map[string][]Result)
+	var pendingDone []string
+
+	for results != nil || doneURLs != nil {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			foundAny = true
+			switch outputFormat {
+			case "jsonl":
+				data, _ := json.Marshal(r)
+				fmt.Println(string(data))
+			case "json", "sarif":
+				all = append(all, r)
+			default:
+				grouped[r.URL] = append(grouped[r.URL], r)
+			}
+
+		case u, ok := <-doneURLs:
+			if !ok {
+				doneURLs = nil
+				continue
+			}
+			if outputFormat == "jsonl" {
+				resume.markDone(u)
+			} else {
+				pendingDone = append(pendingDone, u)
+			}
 		}
-		grouped[r.URL][r.Param] = r.Chars
 	}
 
-	/* === PRINT === */
-	for url, params := range grouped {
-		fmt.Printf("%s[REFLECTED]%s %s\n", green, reset, url)
-		for param, chars := range params {
-			fmt.Printf("    %sParam:%s %s\n", pink, reset, param)
-			fmt.Printf("    Unfiltered: %v\n\n", chars)
+	switch outputFormat {
+	case "json":
+		data, _ := json.MarshalIndent(all, "", "  ")
+		fmt.Println(string(data))
+
+	case "sarif":
+		fmt.Println(toSARIF(all))
+
+	case "jsonl":
+		// already streamed above
+
+	default:
+		for url, rs := range grouped {
+			fmt.Printf("%s[REFLECTED]%s %s\n", green, reset, url)
+			for _, r := range rs {
+				fmt.Printf("    %sParam:%s %s (%s)\n", pink, reset, r.Param, r.Location)
+				fmt.Printf("    Context: %s, Breaks: %v\n\n", r.Context, r.Chars)
+			}
 		}
 	}
 
-	if !foundAny {
-		fmt.Printf("%s[-] No reflected XSS parameters found%s\n", red, reset)
+	for _, u := range pendingDone {
+		resume.markDone(u)
 	}
+
+	return foundAny
+}
+
+/* ========= RESUME STATE ========= */
+// resumeState tracks which URLs have already been fully scanned (keyed by a
+// hash, not the raw URL, to keep the state file small and stable) so a long
+// scan can be interrupted and restarted without redoing work.
+type resumeState struct {
+	mu   sync.Mutex
+	path string
+	done map[string]bool
+}
+
+func loadResumeState(path string) *resumeState {
+	rs := &resumeState{path: path, done: make(map[string]bool)}
+	if path == "" {
+		return rs
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		json.Unmarshal(data, &rs.done)
+	}
+	return rs
+}
+
+func (rs *resumeState) isDone(target string) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.done[hashURL(target)]
+}
+
+func (rs *resumeState) markDone(target string) {
+	if rs.path == "" {
+		return
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.done[hashURL(target)] = true
+
+	if data, err := json.Marshal(rs.done); err == nil {
+		os.WriteFile(rs.path, data, 0644)
+	}
+}
+
+func hashURL(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return hex.EncodeToString(sum[:])
+}
+
+/* ========= SARIF OUTPUT ========= */
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+// toSARIF packages findings for GitHub code-scanning upload.
+func toSARIF(findings []Result) string {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:  "refxss",
+			Rules: []sarifRule{{ID: "reflected-xss"}},
+		}},
+	}
+
+	for _, r := range findings {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    "reflected-xss",
+			Message:   sarifMessage{Text: fmt.Sprintf("Reflected XSS candidate: param %q (%s), context %s, breaks %v", r.Param, r.Location, r.Context, r.Chars)},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifact{URI: r.URL}}}},
+		})
+	}
+
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+
+	data, _ := json.MarshalIndent(log, "", "  ")
+	return string(data)
+}
+
+/* ========= SCAN MODE / COOKIE SETUP ========= */
+func parseScanModes(s string) map[string]bool {
+	modes := make(map[string]bool)
+	for _, m := range strings.Split(s, ",") {
+		m = strings.TrimSpace(m)
+		if m != "" {
+			modes[m] = true
+		}
+	}
+	return modes
+}
+
+// loadBaseCookies builds the cookie set to replay on every request, from
+// -cookie and -cookie-jar (both use the literal "Cookie:" header syntax).
+func loadBaseCookies() []*http.Cookie {
+	var cookies []*http.Cookie
+
+	if cookieFlag != "" {
+		cookies = append(cookies, parseCookieLine(cookieFlag)...)
+	}
+
+	if cookieJarFlag != "" {
+		data, err := os.ReadFile(cookieJarFlag)
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				line = strings.TrimSpace(line)
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				cookies = append(cookies, parseCookieLine(line)...)
+			}
+		}
+	}
+
+	return cookies
+}
+
+func parseCookieLine(raw string) []*http.Cookie {
+	h := http.Header{}
+	h.Set("Cookie", raw)
+	req := &http.Request{Header: h}
+	return req.Cookies()
 }
 
 /* ========= URL NORMALIZATION ========= */
@@ -142,7 +478,7 @@ func normalizeURL(u string) string {
 
 /* ========= STEP 1: FIND REFLECTION ========= */
 func findReflectedParams(client *http.Client, target string) []string {
-	resp, body := doRequest(client, target)
+	resp, body := doRequest(client, method, target, bodyTemplate)
 	if resp == nil {
 		return nil
 	}
@@ -177,56 +513,556 @@ func findReflectedParams(client *http.Client, target string) []string {
 }
 
 /* ========= STEP 2: SPECIAL CHAR TEST ========= */
-func testSpecialChars(client *http.Client, target, param string) []string {
-	chars := []string{
-		`"`, `'`, `<`, `>`, `$`, `|`,
-		`(`, `)`, "`", ":", ";", "{", "}",
+func testSpecialChars(client *http.Client, target, param string) (string, []string) {
+	return scanReflection(func(payload string) string {
+		return applyQuery(client, target, param, payload)
+	})
+}
+
+/* ========= APPEND & CHECK ========= */
+func applyQuery(client *http.Client, target, param, payload string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
 	}
 
-	var unfiltered []string
+	q := u.Query()
+	q.Set(param, q.Get(param)+payload)
+	u.RawQuery = q.Encode()
 
-	for _, c := range chars {
-		payload := prefix + c + suffix
-		if checkAppend(client, target, param, payload) {
-			unfiltered = append(unfiltered, c)
+	_, body := doRequest(client, method, u.String(), bodyTemplate)
+	return body
+}
+
+/* ========= STEP 1b: FIND REFLECTION IN BODY ========= */
+func findReflectedBodyParams(client *http.Client, target string) []bodyParam {
+	resp, respBody := doRequest(client, method, target, bodyTemplate)
+	if resp == nil {
+		return nil
+	}
+
+	if !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		return nil
+	}
+
+	var reflected []bodyParam
+	for _, bp := range extractBodyParams(bodyTemplate, contentType) {
+		if strings.Contains(respBody, bp.Value) ||
+			strings.Contains(respBody, url.QueryEscape(bp.Value)) {
+			reflected = append(reflected, bp)
 		}
 	}
 
-	return unfiltered
+	return reflected
 }
 
-/* ========= APPEND & CHECK ========= */
-func checkAppend(client *http.Client, target, param, payload string) bool {
-	u, err := url.Parse(target)
+/* ========= STEP 2b: SPECIAL CHAR TEST (BODY) ========= */
+func testSpecialCharsBody(client *http.Client, target string, bp bodyParam) (string, []string) {
+	return scanReflection(func(payload string) string {
+		return applyBody(client, target, bp, payload)
+	})
+}
+
+/* ========= APPEND & CHECK (BODY) ========= */
+func applyBody(client *http.Client, target string, bp bodyParam, payload string) string {
+	mutated, err := setBodyParam(bodyTemplate, contentType, bp, bp.Value+payload)
+	if err != nil {
+		return ""
+	}
+
+	_, respBody := doRequest(client, method, target, mutated)
+	return respBody
+}
+
+/* ========= BODY WALKING ========= */
+// extractBodyParams parses a body template (form-encoded or JSON) and
+// returns every mutable leaf it finds.
+func extractBodyParams(body, contentType string) []bodyParam {
+	if body == "" {
+		return nil
+	}
+
+	if strings.Contains(contentType, "json") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			return nil
+		}
+		var leaves []bodyParam
+		walkJSON(nil, "$", v, &leaves)
+		return leaves
+	}
+
+	vals, err := url.ParseQuery(body)
 	if err != nil {
+		return nil
+	}
+
+	var leaves []bodyParam
+	for k, vs := range vals {
+		if len(vs) == 0 {
+			continue
+		}
+		leaves = append(leaves, bodyParam{Location: "body", Key: k, Value: vs[0]})
+	}
+	return leaves
+}
+
+// walkJSON recursively collects string leaves, tracking both the navigation
+// path (for mutation) and a human-readable "$.a.b[0]" path (for display).
+func walkJSON(path []interface{}, display string, v interface{}, leaves *[]bodyParam) {
+	switch val := v.(type) {
+	case string:
+		*leaves = append(*leaves, bodyParam{
+			Location: "json:" + display,
+			Key:      display,
+			Value:    val,
+			jsonPath: path,
+		})
+	case map[string]interface{}:
+		for k, vv := range val {
+			walkJSON(append(append([]interface{}{}, path...), k), display+"."+k, vv, leaves)
+		}
+	case []interface{}:
+		for i, vv := range val {
+			walkJSON(append(append([]interface{}{}, path...), i), fmt.Sprintf("%s[%d]", display, i), vv, leaves)
+		}
+	}
+}
+
+// setBodyParam returns a copy of the body template with bp's value replaced
+// by newValue.
+func setBodyParam(body, contentType string, bp bodyParam, newValue string) (string, error) {
+	if strings.Contains(contentType, "json") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			return "", err
+		}
+		if err := setJSONPath(&v, bp.jsonPath, newValue); err != nil {
+			return "", err
+		}
+		out, err := json.Marshal(v)
+		return string(out), err
+	}
+
+	vals, err := url.ParseQuery(body)
+	if err != nil {
+		return "", err
+	}
+	vals.Set(bp.Key, newValue)
+	return vals.Encode(), nil
+}
+
+// setJSONPath walks root along path and overwrites the final element with
+// newValue. path entries are either a string (map key) or an int (slice index).
+func setJSONPath(root *interface{}, path []interface{}, newValue string) error {
+	if len(path) == 0 {
+		*root = newValue
+		return nil
+	}
+
+	cur := root
+	for i, seg := range path {
+		last := i == len(path)-1
+		switch key := seg.(type) {
+		case string:
+			m, ok := (*cur).(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("refxss: expected object at %v", path[:i+1])
+			}
+			if last {
+				m[key] = newValue
+				return nil
+			}
+			child := m[key]
+			cur = &child
+		case int:
+			s, ok := (*cur).([]interface{})
+			if !ok || key >= len(s) {
+				return fmt.Errorf("refxss: expected array at %v", path[:i+1])
+			}
+			if last {
+				s[key] = newValue
+				return nil
+			}
+			cur = &s[key]
+		default:
+			return fmt.Errorf("refxss: unsupported path segment %v", seg)
+		}
+	}
+	return nil
+}
+
+/* ========= STEP 1c: FIND REFLECTION IN HEADERS ========= */
+func findReflectedHeaders(client *http.Client, target string) []string {
+	canary := prefix + suffix
+
+	var reflected []string
+	for _, h := range candidateHeaders {
+		resp, body := doRequestWithExtras(client, method, target, bodyTemplate, map[string]string{h: canary}, nil)
+		if resp == nil || !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+			continue
+		}
+		if strings.Contains(body, canary) {
+			reflected = append(reflected, h)
+		}
+	}
+	return reflected
+}
+
+/* ========= STEP 2c: SPECIAL CHAR TEST (HEADER) ========= */
+func testSpecialCharsHeader(client *http.Client, target, name string) (string, []string) {
+	return scanReflection(func(payload string) string {
+		return applyHeader(client, target, name, payload)
+	})
+}
+
+func applyHeader(client *http.Client, target, name, payload string) string {
+	_, body := doRequestWithExtras(client, method, target, bodyTemplate, map[string]string{name: payload}, nil)
+	return body
+}
+
+/* ========= STEP 1d: FIND REFLECTION IN COOKIES ========= */
+func findReflectedCookies(client *http.Client, target string) []string {
+	canary := prefix + suffix
+
+	var reflected []string
+	for _, c := range baseCookies {
+		resp, body := doRequestWithExtras(client, method, target, bodyTemplate, nil, &http.Cookie{Name: c.Name, Value: canary})
+		if resp == nil || !strings.Contains(resp.Header.Get("Content-Type"), "html") {
+			continue
+		}
+		if strings.Contains(body, canary) {
+			reflected = append(reflected, c.Name)
+		}
+	}
+	return reflected
+}
+
+/* ========= STEP 2d: SPECIAL CHAR TEST (COOKIE) ========= */
+func testSpecialCharsCookie(client *http.Client, target, name string) (string, []string) {
+	return scanReflection(func(payload string) string {
+		return applyCookie(client, target, name, payload)
+	})
+}
+
+func applyCookie(client *http.Client, target, name, payload string) string {
+	_, body := doRequestWithExtras(client, method, target, bodyTemplate, nil, &http.Cookie{Name: name, Value: payload})
+	return body
+}
+
+/* ========= STEP 3: CONTEXT-AWARE PAYLOAD SELECTION ========= */
+// scanReflection locates the canary via apply, classifies the HTML/JS
+// context it landed in, then probes only the characters that matter in
+// that context (e.g. a bare "<" is pointless inside a JSON string literal).
+func scanReflection(apply func(payload string) string) (string, []string) {
+	canary := prefix + suffix
+	body := apply(canary)
+
+	idx := strings.Index(body, canary)
+	if idx == -1 {
+		if esc := url.QueryEscape(canary); strings.Contains(body, esc) {
+			idx = strings.Index(body, esc)
+		}
+	}
+	if idx == -1 {
+		return "", nil
+	}
+
+	ctx := classifyContext(body, idx)
+
+	var chars []string
+	for _, c := range probesForContext(ctx) {
+		payload := prefix + c + suffix
+		if strings.Contains(apply(payload), payload) {
+			chars = append(chars, c)
+		}
+	}
+
+	return ctx, chars
+}
+
+// probesForContext returns the characters worth testing for a given
+// reflection context. Unknown contexts fall back to the original
+// unconditional probe set.
+func probesForContext(ctx string) []string {
+	switch ctx {
+	case "html_text":
+		return []string{"<", ">"}
+	case "attr_double":
+		return []string{`"`, ">"}
+	case "attr_single":
+		return []string{"'", ">"}
+	case "attr_unquoted":
+		return []string{" ", ">", "="}
+	case "script_string":
+		return []string{`"`, "'", `\`, "</script>"}
+	case "script_block":
+		return []string{"</script>", ";", "\n"}
+	case "comment":
+		return []string{"-->"}
+	case "url_attr":
+		return []string{":", `"`, "'", ">"}
+	default:
+		return []string{
+			`"`, `'`, `<`, `>`, `$`, `|`,
+			`(`, `)`, "`", ":", ";", "{", "}",
+		}
+	}
+}
+
+/* ========= CONTEXT CLASSIFIER ========= */
+type ctxState int
+
+const (
+	csText ctxState = iota
+	csTagName
+	csTagAttrs
+	csAttrName
+	csAfterEq
+	csAttrDouble
+	csAttrSingle
+	csAttrUnquoted
+	csComment
+	csScriptBlock
+	csScriptString
+	csStyleBlock
+)
+
+// classifyContext walks body up to idx tracking a small state machine over
+// tag boundaries, quoted attributes, <script>/<style>, and comments, and
+// reports which markup context byte idx falls in. Unbalanced or ambiguous
+// markup is conservatively reported as html_text.
+func classifyContext(body string, idx int) string {
+	st := csText
+	tagName := ""
+	attrName := ""
+	var quote byte
+
+	n := idx
+	if n > len(body) {
+		n = len(body)
+	}
+
+	for i := 0; i < n; i++ {
+		c := body[i]
+
+		switch st {
+		case csText:
+			if strings.HasPrefix(body[i:], "<!--") {
+				st = csComment
+			} else if c == '<' {
+				st = csTagName
+				tagName = ""
+			}
+
+		case csTagName:
+			switch {
+			case c == '/' && tagName == "":
+				// closing-tag marker, keep reading the name
+			case isTagNameChar(c):
+				tagName += string(c)
+			case c == '>':
+				st = tagBodyState(tagName)
+			default:
+				st = csTagAttrs
+			}
+
+		case csTagAttrs:
+			switch {
+			case c == '>':
+				st = tagBodyState(tagName)
+			case c == '"':
+				quote = '"'
+				st = csAttrDouble
+			case c == '\'':
+				quote = '\''
+				st = csAttrSingle
+			case isTagNameChar(c):
+				attrName = string(c)
+				st = csAttrName
+			}
+
+		case csAttrName:
+			switch {
+			case isTagNameChar(c):
+				attrName += string(c)
+			case c == '=':
+				st = csAfterEq
+			default:
+				attrName = ""
+				st = csTagAttrs
+			}
+
+		case csAfterEq:
+			switch c {
+			case '"':
+				quote = '"'
+				st = csAttrDouble
+			case '\'':
+				quote = '\''
+				st = csAttrSingle
+			case ' ', '>':
+				attrName = ""
+				st = csTagAttrs
+			default:
+				st = csAttrUnquoted
+			}
+
+		case csAttrUnquoted:
+			if c == ' ' || c == '>' {
+				attrName = ""
+				st = csTagAttrs
+			}
+
+		case csAttrDouble, csAttrSingle:
+			if c == quote {
+				attrName = ""
+				st = csTagAttrs
+			}
+
+		case csComment:
+			if strings.HasPrefix(body[i:], "-->") {
+				st = csText
+			}
+
+		case csScriptBlock:
+			if hasPrefixFold(body, i, "</script") {
+				st = csText
+			} else if c == '"' || c == '\'' {
+				quote = c
+				st = csScriptString
+			}
+
+		case csScriptString:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				st = csScriptBlock
+			}
+
+		case csStyleBlock:
+			if hasPrefixFold(body, i, "</style") {
+				st = csText
+			}
+		}
+	}
+
+	switch st {
+	case csComment:
+		return "comment"
+	case csScriptString:
+		return "script_string"
+	case csScriptBlock:
+		return "script_block"
+	case csAttrDouble:
+		return attrContext(attrName, "attr_double")
+	case csAttrSingle:
+		return attrContext(attrName, "attr_single")
+	case csAttrUnquoted:
+		return attrContext(attrName, "attr_unquoted")
+	case csAfterEq:
+		// Reflection starts right at the "=" with no quote consumed yet —
+		// that's exactly where an unquoted attribute value begins.
+		return attrContext(attrName, "attr_unquoted")
+	default:
+		// csText, and any tag/attr-name state that never resolved to a
+		// value: too ambiguous to trust, treat as plain HTML text.
+		return "html_text"
+	}
+}
+
+func attrContext(attrName, quoted string) string {
+	if isURLAttr(attrName) {
+		return "url_attr"
+	}
+	return quoted
+}
+
+// hasPrefixFold reports whether body[i:] starts with want, comparing
+// case-insensitively without allocating a lowercased copy of the remainder.
+func hasPrefixFold(body string, i int, want string) bool {
+	if i+len(want) > len(body) {
 		return false
 	}
+	return strings.EqualFold(body[i:i+len(want)], want)
+}
 
-	q := u.Query()
-	q.Set(param, q.Get(param)+payload)
-	u.RawQuery = q.Encode()
+func isTagNameChar(c byte) bool {
+	return c == '-' || c == '_' || c == ':' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
 
-	_, body := doRequest(client, u.String())
-	return strings.Contains(body, payload)
+func isURLAttr(name string) bool {
+	switch strings.ToLower(name) {
+	case "href", "src", "action", "formaction", "poster", "background", "cite", "data":
+		return true
+	}
+	return false
+}
+
+func tagBodyState(tagName string) ctxState {
+	switch strings.ToLower(tagName) {
+	case "script":
+		return csScriptBlock
+	case "style":
+		return csStyleBlock
+	default:
+		return csText
+	}
 }
 
 /* ========= HTTP ========= */
-func doRequest(client *http.Client, target string) (*http.Response, string) {
+func doRequest(client *http.Client, method, target, body string) (*http.Response, string) {
+	return doRequestWithExtras(client, method, target, body, nil, nil)
+}
+
+// doRequestWithExtras is doRequest plus per-call header overrides and a
+// single cookie override, used to fuzz one header/cookie at a time without
+// disturbing the rest of the baseline request.
+func doRequestWithExtras(client *http.Client, method, target, body string, extraHeaders map[string]string, overrideCookie *http.Cookie) (*http.Response, string) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bodyReader)
 	if err != nil {
 		return nil, ""
 	}
 
+	if body != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
 	req.Header.Set("User-Agent", "refxss/1.0")
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
 	for _, h := range headers {
 		parts := strings.SplitN(h, ":", 2)
 		if len(parts) == 2 {
 			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
 		}
 	}
+	if forwardedFlag {
+		req.Header.Set("X-Forwarded-For", "127.0.0.1")
+		req.Header.Set("X-Forwarded-Host", req.URL.Host)
+		req.Header.Set("X-Forwarded-Proto", req.URL.Scheme)
+	}
+	for name, val := range extraHeaders {
+		req.Header.Set(name, val)
+	}
+
+	for _, c := range baseCookies {
+		if overrideCookie != nil && c.Name == overrideCookie.Name {
+			continue
+		}
+		req.AddCookie(c)
+	}
+	if overrideCookie != nil {
+		req.AddCookie(overrideCookie)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil || resp.Body == nil {
@@ -234,7 +1070,12 @@ func doRequest(client *http.Client, target string) (*http.Response, string) {
 	}
 	defer resp.Body.Close()
 
-	data, err := io.ReadAll(resp.Body)
+	reader, err := decompressReader(resp)
+	if err != nil {
+		return resp, ""
+	}
+
+	data, err := io.ReadAll(reader)
 	if err != nil {
 		return resp, ""
 	}
@@ -242,14 +1083,75 @@ func doRequest(client *http.Client, target string) (*http.Response, string) {
 	return resp, string(data)
 }
 
+/* ========= RESPONSE DECOMPRESSION ========= */
+func decompressReader(resp *http.Response) (io.Reader, error) {
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		return deflateReader(resp.Body)
+	case "br":
+		return brotli.NewReader(resp.Body), nil
+	default:
+		return resp.Body, nil
+	}
+}
+
+// deflateReader handles Content-Encoding: deflate, which despite the name is
+// usually zlib-wrapped DEFLATE (RFC 1950) rather than raw DEFLATE (RFC
+// 1951). Body bytes are buffered so a failed zlib header check can fall
+// back to a raw flate reader over the same data.
+func deflateReader(body io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		return zr, nil
+	}
+	return flate.NewReader(bytes.NewReader(data)), nil
+}
+
 /* ========= CLIENT ========= */
 func buildClient() *http.Client {
-	return &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			DialContext: (&net.Dialer{
-				Timeout: time.Duration(timeout) * time.Second,
-			}).DialContext,
-		},
+	jar, _ := cookiejar.New(nil)
+
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureFlag},
+		DialContext: (&net.Dialer{
+			Timeout: time.Duration(timeout) * time.Second,
+		}).DialContext,
+	}
+
+	if proxyFlag != "" {
+		configureProxy(transport, proxyFlag)
+	}
+
+	return &http.Client{Jar: jar, Transport: transport}
+}
+
+// configureProxy points transport at an upstream HTTP(S) or SOCKS5 proxy,
+// e.g. for routing traffic through Burp or ZAP.
+func configureProxy(transport *http.Transport, proxyURL string) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s[-] Invalid -proxy URL: %v%s\n", red, err, reset)
+		return
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(u)
+	case "socks5":
+		dialer, err := proxy.FromURL(u, &net.Dialer{Timeout: time.Duration(timeout) * time.Second})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s[-] Invalid -proxy SOCKS5 config: %v%s\n", red, err, reset)
+			return
+		}
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.Dial(network, addr)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "%s[-] Unsupported -proxy scheme: %s%s\n", red, u.Scheme, reset)
 	}
 }